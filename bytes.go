@@ -0,0 +1,22 @@
+package websocket
+
+import "sync"
+
+// bytePool recycles small scratch buffers used while building handshake
+// headers (Origin, Sec-WebSocket-Key, the dial address), so those don't
+// allocate on every Dial/UpgradeAsClient call.
+var bytePool = sync.Pool{
+	New: func() interface{} {
+		return make([]byte, 128)
+	},
+}
+
+// extendByteSlice grows b, reusing its existing capacity, so it can hold
+// at least needLen bytes.
+func extendByteSlice(b []byte, needLen int) []byte {
+	b = b[:cap(b)]
+	if n := needLen - cap(b); n > 0 {
+		b = append(b, make([]byte, n)...)
+	}
+	return b[:needLen]
+}
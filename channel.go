@@ -0,0 +1,278 @@
+package websocket
+
+import (
+	"bytes"
+	b64 "encoding/base64"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// wsHeaderProtocol is the header used to offer and negotiate application
+// subprotocols during the handshake.
+var wsHeaderProtocol = []byte("Sec-WebSocket-Protocol")
+
+// Channel identifies one of the multiplexed streams carried over a single
+// WebSocket connection by the channel.k8s.io and base64.channel.k8s.io
+// subprotocols used for kubectl exec/attach.
+type Channel byte
+
+// The stream channels, in the order kubectl exec/attach expects them.
+const (
+	ChannelStdin Channel = iota
+	ChannelStdout
+	ChannelStderr
+	ChannelError
+	ChannelResize
+)
+
+// Subprotocol tokens for the Kubernetes exec/attach stream protocols.
+const (
+	SubprotocolChannel       = "channel.k8s.io"
+	SubprotocolBase64Channel = "base64.channel.k8s.io"
+)
+
+// TerminalSize is the JSON payload carried on ChannelResize, matching the
+// untagged {"Width":N,"Height":N} wire format of k8s's
+// remotecommand.TerminalSize.
+type TerminalSize struct {
+	Width  uint16
+	Height uint16
+}
+
+// ChannelConn multiplexes the Kubernetes exec/attach stream protocol over
+// a *Client: each WebSocket message is prefixed with a single channel
+// identifier byte, demultiplexed here into independent
+// io.ReadWriteCloser streams.
+type ChannelConn struct {
+	c      *Client
+	base64 bool
+
+	streams   [ChannelResize + 1]*channelStream
+	resize    chan TerminalSize
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewChannelConn wraps c, demultiplexing the Kubernetes stream protocol.
+// base64Encoded selects the base64.channel.k8s.io framing, where each
+// channel-prefixed payload is additionally base64-encoded onto text
+// frames; otherwise payloads are sent as raw binary frames.
+func NewChannelConn(c *Client, base64Encoded bool) *ChannelConn {
+	cc := &ChannelConn{
+		c:      c,
+		base64: base64Encoded,
+		resize: make(chan TerminalSize, 1),
+		closed: make(chan struct{}),
+	}
+
+	for ch := range cc.streams {
+		cc.streams[ch] = newChannelStream(Channel(ch), cc)
+	}
+
+	go cc.readLoop()
+
+	return cc
+}
+
+// Stdin, Stdout, Stderr and ErrorStream return the per-channel streams
+// used by kubectl exec/attach.
+func (cc *ChannelConn) Stdin() io.ReadWriteCloser       { return cc.streams[ChannelStdin] }
+func (cc *ChannelConn) Stdout() io.ReadWriteCloser      { return cc.streams[ChannelStdout] }
+func (cc *ChannelConn) Stderr() io.ReadWriteCloser      { return cc.streams[ChannelStderr] }
+func (cc *ChannelConn) ErrorStream() io.ReadWriteCloser { return cc.streams[ChannelError] }
+
+// Resize sends a terminal resize event to the peer on ChannelResize.
+func (cc *ChannelConn) Resize(size TerminalSize) error {
+	b, err := json.Marshal(size)
+	if err != nil {
+		return err
+	}
+
+	return cc.writeChannel(ChannelResize, b)
+}
+
+// Resizes returns the channel on which resize events sent by the peer are
+// delivered. It is closed once the connection shuts down, so a caller
+// ranging over it (for sz := range cc.Resizes()) returns instead of
+// blocking forever.
+func (cc *ChannelConn) Resizes() <-chan TerminalSize {
+	return cc.resize
+}
+
+// Done returns a channel that's closed once readLoop has exited, i.e.
+// once ReadFrame has returned an error and all streams have been closed
+// with it. Callers can select on it to notice connection shutdown
+// without blocking on a specific stream.
+func (cc *ChannelConn) Done() <-chan struct{} {
+	return cc.closed
+}
+
+// Close closes the underlying connection and all channel streams.
+//
+// Unlike Client.Close, it doesn't wait for the peer's close frame reply
+// itself: readLoop is already the sole reader of the connection, and
+// Close's own read would race it for the same bytes. readLoop observes
+// the resulting error and finishes the shutdown via closeStreams.
+func (cc *ChannelConn) Close() error {
+	cc.closeStreams(io.ErrClosedPipe)
+	return cc.c.closeWithoutWaiting()
+}
+
+func (cc *ChannelConn) writeChannel(ch Channel, p []byte) error {
+	if cc.base64 {
+		// base64.channel.k8s.io prefixes an ASCII digit channel, then
+		// base64-encodes only the payload — not the channel byte itself.
+		encoded := make([]byte, 1+b64.StdEncoding.EncodedLen(len(p)))
+		encoded[0] = '0' + byte(ch)
+		b64.StdEncoding.Encode(encoded[1:], p)
+
+		_, err := cc.c.Write(encoded)
+		return err
+	}
+
+	payload := append([]byte{byte(ch)}, p...)
+	_, err := cc.c.WriteBinary(payload)
+	return err
+}
+
+func (cc *ChannelConn) readLoop() {
+	defer close(cc.closed)
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	for {
+		if _, err := cc.c.ReadFrame(fr); err != nil {
+			cc.closeStreams(err)
+			return
+		}
+
+		payload := fr.Payload()
+		if len(payload) == 0 {
+			continue
+		}
+
+		var ch Channel
+		var data []byte
+
+		if cc.base64 {
+			// The channel is the raw ASCII digit '0'+channel, not part of
+			// the base64 blob; only payload[1:] is encoded.
+			ch = Channel(payload[0] - '0')
+
+			decoded := make([]byte, b64.StdEncoding.DecodedLen(len(payload)-1))
+			n, err := b64.StdEncoding.Decode(decoded, payload[1:])
+			if err != nil {
+				continue
+			}
+			data = decoded[:n]
+		} else {
+			ch, data = Channel(payload[0]), payload[1:]
+		}
+
+		if ch == ChannelResize {
+			var size TerminalSize
+			if json.Unmarshal(data, &size) == nil {
+				select {
+				case cc.resize <- size:
+				default:
+				}
+			}
+			continue
+		}
+
+		if int(ch) >= len(cc.streams) {
+			continue
+		}
+
+		cc.streams[ch].deliver(data)
+	}
+}
+
+// closeStreams closes every channel stream plus cc.resize, the latter so
+// a caller doing `for sz := range cc.Resizes()` observes shutdown
+// instead of blocking forever. It is idempotent: readLoop calls it on
+// ReadFrame's error return, and Close calls it directly, and both can
+// race when Close triggers readLoop's exit by closing the underlying
+// connection out from under it.
+func (cc *ChannelConn) closeStreams(err error) {
+	cc.closeOnce.Do(func() {
+		for _, s := range cc.streams {
+			s.closeWithError(err)
+		}
+		close(cc.resize)
+	})
+}
+
+// channelStream is the io.ReadWriteCloser backing a single Channel. Reads
+// drain a buffer fed by ChannelConn.readLoop via deliver; buffering
+// (rather than an io.Pipe, whose Write blocks until a reader drains it)
+// keeps a slow or absent consumer on one channel from stalling the shared
+// demux loop and starving the others. Writes go straight back out
+// through the shared connection.
+type channelStream struct {
+	ch   Channel
+	conn *ChannelConn
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+	err    error
+}
+
+func newChannelStream(ch Channel, conn *ChannelConn) *channelStream {
+	s := &channelStream{ch: ch, conn: conn}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// deliver appends data read from the connection without blocking on a
+// reader.
+func (s *channelStream) deliver(data []byte) {
+	s.mu.Lock()
+	s.buf.Write(data)
+	s.cond.Signal()
+	s.mu.Unlock()
+}
+
+func (s *channelStream) closeWithError(err error) {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		s.err = err
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+}
+
+func (s *channelStream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.buf.Len() == 0 && !s.closed {
+		s.cond.Wait()
+	}
+
+	if s.buf.Len() == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		return 0, io.EOF
+	}
+
+	return s.buf.Read(p)
+}
+
+func (s *channelStream) Write(p []byte) (int, error) {
+	if err := s.conn.writeChannel(s.ch, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *channelStream) Close() error {
+	s.closeWithError(io.EOF)
+	return nil
+}
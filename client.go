@@ -3,10 +3,14 @@ package websocket
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"errors"
 	"net"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/valyala/fasthttp"
@@ -15,12 +19,61 @@ import (
 // ClientConfig allows setting custom options for use when connecting to a client.
 type ClientConfig struct {
 	Origin string
-	using  bool
+
+	// EnableCompression enables negotiation of the permessage-deflate
+	// extension (RFC 7692). When the server does not accept the offer,
+	// the connection falls back to uncompressed frames transparently.
+	EnableCompression bool
+
+	// CompressionLevel is the flate compression level to use for
+	// outgoing frames. Defaults to flate.DefaultCompression when zero.
+	CompressionLevel int
+
+	// CompressionThreshold is the minimum payload size, in bytes, a
+	// message must reach before it is compressed. Messages smaller than
+	// this are sent uncompressed regardless of EnableCompression.
+	CompressionThreshold int
+
+	// Subprotocols lists, in preference order, the application
+	// subprotocols to offer via Sec-WebSocket-Protocol during the
+	// handshake (e.g. "channel.k8s.io").
+	Subprotocols []string
+
+	// NetDial, when set, is used to establish the underlying TCP
+	// connection for DialContext instead of the default net.Dialer.
+	NetDial func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// TLSConfig is used for wss:// connections dialed via DialContext,
+	// in place of the package default tls.Config.
+	TLSConfig *tls.Config
+
+	// HandshakeTimeout bounds the time DialContext spends dialing,
+	// proxying, performing the TLS handshake and reading the upgrade
+	// response. Zero means no timeout beyond ctx's own deadline.
+	HandshakeTimeout time.Duration
+
+	// Proxy returns the proxy to use for a given request URL, or a nil
+	// URL for no proxy. Defaults to http.ProxyFromEnvironment when nil,
+	// so HTTP_PROXY/HTTPS_PROXY/NO_PROXY are honored automatically.
+	Proxy func(*url.URL) (*url.URL, error)
+
+	using bool
 }
 
 var (
 	// ErrCannotUpgrade shows up when an error occurred when upgrading a connection.
-	ErrCannotUpgrade   = errors.New("cannot upgrade connection")
+	ErrCannotUpgrade = errors.New("cannot upgrade connection")
+
+	// ErrSubprotocolMismatch is returned when the server selects a
+	// Sec-WebSocket-Protocol value that was not among cconfig.Subprotocols.
+	ErrSubprotocolMismatch = errors.New("websocket: server selected an unrequested subprotocol")
+
+	// ErrCompressedFragmentation is returned by ReadFrame when a
+	// continuation frame itself carries RSV1, which RFC 7692 §6.1
+	// forbids: RSV1 may only mark a message's first frame, since the
+	// DEFLATE stream it introduces spans every fragment that follows.
+	ErrCompressedFragmentation = errors.New("websocket: continuation frame must not set RSV1")
+
 	unusedClientConfig = ClientConfig{using: false}
 )
 
@@ -28,12 +81,12 @@ var (
 //
 // url must be a complete URL format i.e. http://localhost:8080/ws
 func MakeClient(c net.Conn, url string) (*Client, error) {
-	return client(c, url, nil, unusedClientConfig)
+	return client(context.Background(), c, url, nil, unusedClientConfig)
 }
 
 // ClientWithHeaders returns a Conn using an existing connection and sending custom headers.
 func ClientWithHeaders(c net.Conn, url string, req *fasthttp.Request) (*Client, error) {
-	return client(c, url, req, unusedClientConfig)
+	return client(context.Background(), c, url, req, unusedClientConfig)
 }
 
 // UpgradeAsClient will upgrade the connection as a client
@@ -43,6 +96,22 @@ func ClientWithHeaders(c net.Conn, url string, req *fasthttp.Request) (*Client,
 //
 // r can be nil.
 func UpgradeAsClient(c net.Conn, url string, r *fasthttp.Request, cconfig ClientConfig) error {
+	_, err := upgradeAsClient(context.Background(), c, url, r, cconfig)
+	return err
+}
+
+// negotiation holds everything the handshake agreed on with the server,
+// so that client() can configure the resulting Client accordingly.
+type negotiation struct {
+	compression *compressionParams
+	subprotocol string
+}
+
+// upgradeAsClient performs the client handshake and returns the
+// negotiation result alongside any error. ctx is honored while reading
+// the upgrade response; it has no effect on writing the request, which
+// is flushed synchronously before the read starts.
+func upgradeAsClient(ctx context.Context, c net.Conn, url string, r *fasthttp.Request, cconfig ClientConfig) (*negotiation, error) {
 	var err error
 
 	req := fasthttp.AcquireRequest()
@@ -64,7 +133,7 @@ func UpgradeAsClient(c net.Conn, url string, r *fasthttp.Request, cconfig Client
 		origin, err = prepareCustomOrigin(origin, cconfig.Origin)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		origin = prepareOrigin(origin, uri)
@@ -82,7 +151,14 @@ func UpgradeAsClient(c net.Conn, url string, r *fasthttp.Request, cconfig Client
 	req.Header.AddBytesKV(upgradeString, websocketString)
 	req.Header.AddBytesKV(wsHeaderVersion, supportedVersions[0])
 	req.Header.AddBytesKV(wsHeaderKey, key)
-	// TODO: Add compression
+
+	if cconfig.EnableCompression {
+		req.Header.AddBytesKV(wsHeaderExtensions, compressionOffer())
+	}
+
+	if len(cconfig.Subprotocols) > 0 {
+		req.Header.AddBytesKV(wsHeaderProtocol, []byte(strings.Join(cconfig.Subprotocols, ", ")))
+	}
 
 	req.SetRequestURIBytes(uri.FullURI())
 
@@ -91,24 +167,64 @@ func UpgradeAsClient(c net.Conn, url string, r *fasthttp.Request, cconfig Client
 	req.Write(bw)
 	bw.Flush()
 
-	err = res.Read(br)
-	if err == nil {
-		if res.StatusCode() != 101 ||
-			!equalsFold(res.Header.PeekBytes(upgradeString), websocketString) {
-			err = ErrCannotUpgrade
+	readDone := make(chan error, 1)
+	go func() { readDone <- res.Read(br) }()
+
+	select {
+	case err = <-readDone:
+		if err != nil {
+			return nil, err
 		}
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
 	}
 
-	return err
+	if res.StatusCode() != 101 ||
+		!equalsFold(res.Header.PeekBytes(upgradeString), websocketString) {
+		return nil, ErrCannotUpgrade
+	}
+
+	neg := &negotiation{}
+
+	if cconfig.EnableCompression {
+		neg.compression = parseCompressionResponse(res.Header.PeekBytes(wsHeaderExtensions))
+	}
+
+	if selected := string(res.Header.PeekBytes(wsHeaderProtocol)); selected != "" {
+		if !containsString(cconfig.Subprotocols, selected) {
+			return nil, ErrSubprotocolMismatch
+		}
+		neg.subprotocol = selected
+	}
+
+	return neg, nil
 }
 
-func client(c net.Conn, url string, r *fasthttp.Request, cconfig ClientConfig) (cl *Client, err error) {
-	err = UpgradeAsClient(c, url, r, cconfig)
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func client(ctx context.Context, c net.Conn, url string, r *fasthttp.Request, cconfig ClientConfig) (cl *Client, err error) {
+	neg, err := upgradeAsClient(ctx, c, url, r, cconfig)
 	if err == nil {
 		cl = &Client{
 			c: c,
 			brw: bufio.NewReadWriter(
 				bufio.NewReader(c), bufio.NewWriter(c)),
+			compression:          neg.compression,
+			compressionThreshold: cconfig.CompressionThreshold,
+			subprotocol:          neg.subprotocol,
+		}
+
+		if neg.compression != nil {
+			cl.deflater = newMessageDeflater(cconfig.CompressionLevel, neg.compression.clientNoContextTakeover)
+			cl.inflater = newMessageInflater(neg.compression.serverNoContextTakeover)
 		}
 	}
 
@@ -187,7 +303,7 @@ func dial(url string, cnf *tls.Config, req *fasthttp.Request, cconfig ClientConf
 	}
 
 	if err == nil {
-		conn, err = client(c, uri.String(), req, cconfig)
+		conn, err = client(context.Background(), c, uri.String(), req, cconfig)
 		if err != nil {
 			c.Close()
 		}
@@ -204,45 +320,144 @@ func makeRandKey(b []byte) []byte {
 
 // Client holds a WebSocket connection.
 //
-// The client is NOT concurrently safe. It is intended to be
-// used with the Frame struct.
+// Writes (Write, WriteBinary, WriteFrame) are safe to call from multiple
+// goroutines; they are serialized through an internal mutex, and
+// EnableKeepalive's pinger uses the same path. Reads are not: ReadFrame
+// must be called from a single goroutine at a time, as is standard for
+// WebSocket connections.
 type Client struct {
 	c   net.Conn
 	brw *bufio.ReadWriter
+
+	// isServer is true for connections created by Upgrader.Upgrade and
+	// false for ones created by Dial/MakeClient/etc. It decides which
+	// side of the connection maskOutgoing masks frames for, per RFC 6455
+	// §5.3: only frames sent from client to server are masked.
+	isServer bool
+
+	writeMu sync.Mutex
+
+	// compression holds the negotiated permessage-deflate parameters, or
+	// nil if compression was not negotiated for this connection.
+	// deflater/inflater apply it per-direction, honoring whichever side's
+	// no_context_takeover was negotiated.
+	compression          *compressionParams
+	compressionThreshold int
+	deflater             *messageDeflater
+	inflater             *messageInflater
+
+	// subprotocol is the application subprotocol selected by the server,
+	// or empty if none was negotiated.
+	subprotocol string
+
+	// keepalive holds the config passed to EnableKeepalive, and pongMu/
+	// lastPong track the most recent pong for its liveness check.
+	// handlerMu additionally guards keepalive, pingHandler and
+	// pongHandler themselves, since EnableKeepalive/SetPingHandler/
+	// SetPongHandler can race with ReadFrame's handling of an
+	// already-in-flight ping or pong on another goroutine.
+	handlerMu   sync.Mutex
+	keepalive   KeepaliveConfig
+	pongMu      sync.Mutex
+	lastPong    time.Time
+	pingHandler func(appData []byte)
+	pongHandler func(appData []byte)
+
+	// fragCompressed and the fields below it track a fragmented message
+	// currently being read whose first frame had RSV1 set: per RFC 7692
+	// §7.2.1, the DEFLATE stream spans the whole message, so its
+	// continuation frames can't be inflated independently. ReadFrame
+	// instead buffers their raw payloads in fragBuf and only inflates
+	// once the final fragment arrives, returning the reassembled message
+	// as a single frame carrying fragCode (the first fragment's opcode).
+	// Only ReadFrame's caller goroutine touches these.
+	fragCompressed bool
+	fragCode       Code
+	fragBuf        []byte
 }
 
 // Write writes the content `b` as text.
 //
-// To send binary content use WriteBinary.
+// To send binary content use WriteBinary. To opt this particular message
+// out of negotiated compression, use WriteUncompressed.
 func (c *Client) Write(b []byte) (int, error) {
-	fr := AcquireFrame()
-	defer ReleaseFrame(fr)
-
-	fr.SetFin()
-	fr.SetPayload(b)
-	fr.SetText()
-	fr.Mask()
-
-	return c.WriteFrame(fr)
+	return c.write(b, false, false)
 }
 
 // WriteBinary writes the content `b` as binary.
 //
-// To send text content use Write.
+// To send text content use Write. To opt this particular message out of
+// negotiated compression, use WriteBinaryUncompressed.
 func (c *Client) WriteBinary(b []byte) (int, error) {
+	return c.write(b, true, false)
+}
+
+// WriteUncompressed writes the content `b` as text, like Write, but
+// always sends it uncompressed even when permessage-deflate was
+// negotiated and b reaches CompressionThreshold. This is the per-message
+// opt-out chunk0-1 added ClientConfig.EnableCompression/Frame.SetCompressed
+// for.
+func (c *Client) WriteUncompressed(b []byte) (int, error) {
+	return c.write(b, false, true)
+}
+
+// WriteBinaryUncompressed is WriteUncompressed for binary content.
+func (c *Client) WriteBinaryUncompressed(b []byte) (int, error) {
+	return c.write(b, true, true)
+}
+
+func (c *Client) write(b []byte, binary, skipCompression bool) (int, error) {
+	// Locked for the whole call, not just the wire write: c.deflater
+	// keeps state (its sliding window) across messages when context
+	// takeover was negotiated, so compression itself must be serialized
+	// the same as the write it feeds.
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
 	fr := AcquireFrame()
 	defer ReleaseFrame(fr)
 
+	payload, compressed := b, false
+	if !skipCompression && c.deflater != nil && len(b) >= c.compressionThreshold {
+		if cb, err := c.deflater.deflate(b); err == nil {
+			payload, compressed = cb, true
+		}
+	}
+
 	fr.SetFin()
-	fr.SetPayload(b)
-	fr.SetBinary()
-	fr.Mask()
+	fr.SetPayload(payload)
+	if binary {
+		fr.SetBinary()
+	} else {
+		fr.SetText()
+	}
+	fr.SetCompressed(compressed)
+	c.maskOutgoing(fr)
 
-	return c.WriteFrame(fr)
+	return c.writeFrameLocked(fr)
 }
 
-// WriteFrame writes the frame into the WebSocket connection.
+// maskOutgoing masks fr if c represents the client side of the
+// connection. Per RFC 6455 §5.3, masking applies only to frames sent
+// from client to server; a server must send unmasked frames, so
+// handlers writing through a Client obtained from Upgrader.Upgrade
+// leave fr unmasked.
+func (c *Client) maskOutgoing(fr *Frame) {
+	if !c.isServer {
+		fr.Mask()
+	}
+}
+
+// WriteFrame writes the frame into the WebSocket connection. It is safe
+// to call concurrently with other writes.
 func (c *Client) WriteFrame(fr *Frame) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.writeFrameLocked(fr)
+}
+
+func (c *Client) writeFrameLocked(fr *Frame) (int, error) {
 	nn, err := fr.WriteTo(c.brw)
 	if err == nil {
 		err = c.brw.Flush()
@@ -251,22 +466,103 @@ func (c *Client) WriteFrame(fr *Frame) (int, error) {
 	return int(nn), err
 }
 
-// ReadFrame reads a frame from the connection.
+// ReadFrame reads a frame from the connection, transparently inflating
+// the payload when the frame was negotiated and marked as compressed.
+// Ping and pong control frames are handled internally (replying to pings
+// and feeding the configured handlers) and never returned to the caller;
+// ReadFrame loops until a data or close frame arrives.
+//
+// A compressed message fragmented across multiple frames (RSV1 is only
+// ever set on the first one, per RFC 7692 §7.2.1, since the DEFLATE
+// stream spans the whole message) is reassembled internally: its
+// continuation frames are buffered rather than returned, and once the
+// final one arrives ReadFrame inflates the concatenated stream and
+// returns it as a single frame carrying the first fragment's opcode.
+// Uncompressed fragmented messages are unaffected and still returned to
+// the caller frame by frame, as before. ErrCompressedFragmentation is
+// returned if a continuation frame carries RSV1 itself, which RFC 7692
+// §6.1 forbids.
 func (c *Client) ReadFrame(fr *Frame) (int, error) {
-	n, err := fr.ReadFrom(c.brw)
-	return int(n), err
+	for {
+		n, err := fr.ReadFrom(c.brw)
+		if err != nil {
+			return int(n), err
+		}
+
+		if fr.IsPing() {
+			c.handlePing(fr)
+			continue
+		}
+
+		if fr.IsPong() {
+			c.handlePong(fr)
+			continue
+		}
+
+		if fr.IsContinuation() {
+			if fr.Rsv1() {
+				return int(n), ErrCompressedFragmentation
+			}
+
+			if !c.fragCompressed {
+				return int(n), nil
+			}
+
+			c.fragBuf = append(c.fragBuf, fr.Payload()...)
+			if !fr.Fin() {
+				continue
+			}
+
+			c.fragCompressed = false
+			payload := c.fragBuf
+			c.fragBuf = nil
+
+			if c.inflater != nil {
+				inflated, ierr := c.inflater.inflate(payload)
+				if ierr != nil {
+					return int(n), ierr
+				}
+				payload = inflated
+			}
+
+			fr.SetCode(c.fragCode)
+			fr.SetFin()
+			fr.SetPayload(payload)
+			return int(n), nil
+		}
+
+		if !fr.Fin() {
+			// First frame of a fragmented message: a compressed one is
+			// buffered and reassembled above, an uncompressed one is
+			// returned to the caller like any other frame.
+			c.fragCompressed = fr.Compressed()
+			c.fragCode = fr.Code()
+			if c.fragCompressed {
+				c.fragBuf = append(c.fragBuf[:0], fr.Payload()...)
+				continue
+			}
+			return int(n), nil
+		}
+
+		if c.inflater != nil && fr.Compressed() {
+			payload, ierr := c.inflater.inflate(fr.Payload())
+			if ierr != nil {
+				return int(n), ierr
+			}
+
+			fr.SetPayload(payload)
+		}
+
+		return int(n), nil
+	}
 }
 
 // Close gracefully closes the websocket connection.
 func (c *Client) Close() error {
 	fr := AcquireFrame()
-	fr.SetClose()
-	fr.SetFin()
-
-	fr.SetStatus(StatusNone)
+	defer ReleaseFrame(fr)
 
-	_, err := c.WriteFrame(fr)
-	if err != nil {
+	if err := c.writeCloseFrame(fr); err != nil {
 		return err
 	}
 
@@ -276,3 +572,42 @@ func (c *Client) Close() error {
 
 	return c.c.Close()
 }
+
+// writeCloseFrame sends a close frame with status StatusNone, masked as
+// appropriate for c's side of the connection.
+func (c *Client) writeCloseFrame(fr *Frame) error {
+	fr.SetClose()
+	fr.SetFin()
+	fr.SetStatus(StatusNone)
+	c.maskOutgoing(fr)
+
+	_, err := c.WriteFrame(fr)
+	return err
+}
+
+// closeWithoutWaiting sends a close frame and closes the underlying
+// connection, without reading for the peer's reply itself like Close
+// does. It's for callers, such as ChannelConn, that already have a
+// dedicated goroutine reading the connection: Close's own ReadFrame
+// call would otherwise race that goroutine for the same bytes.
+func (c *Client) closeWithoutWaiting() error {
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	c.writeCloseFrame(fr)
+
+	return c.c.Close()
+}
+
+// Subprotocol returns the application subprotocol negotiated with the
+// server, or the empty string if none was requested or selected.
+func (c *Client) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Channel wraps the connection in a ChannelConn for the Kubernetes
+// exec/attach stream protocol, using the base64.channel.k8s.io framing
+// when that subprotocol was negotiated during the handshake.
+func (c *Client) Channel() *ChannelConn {
+	return NewChannelConn(c, c.subprotocol == SubprotocolBase64Channel)
+}
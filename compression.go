@@ -0,0 +1,205 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// permessageDeflate is the extension token for RFC 7692 compression
+// negotiated via the Sec-WebSocket-Extensions header.
+const permessageDeflate = "permessage-deflate"
+
+var (
+	wsHeaderExtensions = []byte("Sec-WebSocket-Extensions")
+
+	// trailingFlateBytes is the 4-byte block appended by a DEFLATE stream
+	// flush that RFC 7692 §7.2.1 requires senders to strip and receivers
+	// to re-append before decompressing.
+	trailingFlateBytes = []byte{0x00, 0x00, 0xff, 0xff}
+
+	// inflateTail is trailingFlateBytes plus an empty final stored block
+	// (BFINAL=1, BTYPE=00, zero-length). trailingFlateBytes alone is a
+	// non-final block, so without this, flate.Reader goes looking for a
+	// following block and reports io.ErrUnexpectedEOF once the message
+	// ends — the same gotcha gorilla/websocket and nhooyr.io/websocket
+	// paper over by appending these same bytes.
+	inflateTail = append(append([]byte(nil), trailingFlateBytes...), 0x01, 0x00, 0x00, 0xff, 0xff)
+)
+
+// maxWindowBits is the DEFLATE window size messageInflater's dictionary is
+// capped at; this package never negotiates client_max_window_bits /
+// server_max_window_bits down from their default, so peers never use a
+// smaller one.
+const maxWindowBits = 32768
+
+// compressionParams holds the permessage-deflate parameters negotiated
+// for a single connection, as defined by RFC 7692 §7.1.
+type compressionParams struct {
+	serverNoContextTakeover bool
+	clientNoContextTakeover bool
+}
+
+// compressionOffer builds the Sec-WebSocket-Extensions header value used
+// to offer permessage-deflate during the client handshake.
+func compressionOffer() []byte {
+	return []byte(permessageDeflate + "; client_max_window_bits")
+}
+
+// parseCompressionResponse parses the server's Sec-WebSocket-Extensions
+// response header, returning the negotiated parameters. It returns a nil
+// compressionParams, without error, when the server did not accept the
+// permessage-deflate offer.
+func parseCompressionResponse(header []byte) *compressionParams {
+	return parseCompressionExtension(header)
+}
+
+// parseCompressionOffer parses a client's Sec-WebSocket-Extensions request
+// header the same way parseCompressionResponse parses the server's, so
+// Upgrader can mirror ClientConfig's negotiation: it returns a nil
+// compressionParams, without error, when the client did not offer
+// permessage-deflate.
+func parseCompressionOffer(header []byte) *compressionParams {
+	return parseCompressionExtension(header)
+}
+
+// parseCompressionExtension extracts the permessage-deflate token and its
+// *_no_context_takeover parameters from a Sec-WebSocket-Extensions header,
+// client or server side; the wire format is the same in both directions.
+func parseCompressionExtension(header []byte) *compressionParams {
+	if len(header) == 0 {
+		return nil
+	}
+
+	for _, ext := range bytes.Split(header, []byte(",")) {
+		fields := bytes.Split(bytes.TrimSpace(ext), []byte(";"))
+		if len(fields) == 0 || string(bytes.TrimSpace(fields[0])) != permessageDeflate {
+			continue
+		}
+
+		cp := &compressionParams{}
+		for _, f := range fields[1:] {
+			switch string(bytes.TrimSpace(f)) {
+			case "server_no_context_takeover":
+				cp.serverNoContextTakeover = true
+			case "client_no_context_takeover":
+				cp.clientNoContextTakeover = true
+			}
+		}
+
+		return cp
+	}
+
+	return nil
+}
+
+// compressionAccept builds the Sec-WebSocket-Extensions response header
+// value Upgrader uses to accept a client's permessage-deflate offer,
+// honoring cp's *_no_context_takeover parameters so the client configures
+// its own deflater/inflater to match.
+func compressionAccept(cp *compressionParams) []byte {
+	accept := permessageDeflate
+	if cp.serverNoContextTakeover {
+		accept += "; server_no_context_takeover"
+	}
+	if cp.clientNoContextTakeover {
+		accept += "; client_no_context_takeover"
+	}
+	return []byte(accept)
+}
+
+// messageDeflater compresses successive messages for one direction of a
+// connection, honoring the negotiated *_no_context_takeover parameter: a
+// fresh flate.Writer is used for every call when noTakeover is set,
+// otherwise one flate.Writer (and its sliding window) is reused across
+// messages, only its output buffer is cleared between them.
+type messageDeflater struct {
+	level      int
+	noTakeover bool
+
+	buf bytes.Buffer
+	fw  *flate.Writer
+}
+
+func newMessageDeflater(level int, noTakeover bool) *messageDeflater {
+	if level == 0 {
+		level = flate.DefaultCompression
+	}
+
+	return &messageDeflater{level: level, noTakeover: noTakeover}
+}
+
+// deflate compresses b and strips the trailing 0x00 0x00 0xff 0xff block
+// per RFC 7692 §7.2.1.
+func (d *messageDeflater) deflate(b []byte) ([]byte, error) {
+	d.buf.Reset()
+
+	if d.fw == nil {
+		fw, err := flate.NewWriter(&d.buf, d.level)
+		if err != nil {
+			return nil, err
+		}
+		d.fw = fw
+	} else if d.noTakeover {
+		d.fw.Reset(&d.buf)
+	}
+
+	if _, err := d.fw.Write(b); err != nil {
+		return nil, err
+	}
+
+	if err := d.fw.Flush(); err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSuffix(d.buf.Bytes(), trailingFlateBytes)
+	out := make([]byte, len(trimmed))
+	copy(out, trimmed)
+
+	return out, nil
+}
+
+// messageInflater decompresses successive messages for one direction of a
+// connection, honoring the negotiated *_no_context_takeover parameter.
+//
+// Unlike messageDeflater, this can't preserve its window by reusing one
+// flate.Reader across messages: once io.ReadAll on a flate.Reader
+// observes an error, the reader is done and every later Read on it just
+// replays that same error, so a second message would decode as empty.
+// Instead, a fresh flate.Reader is created per message (cleanly
+// terminated via inflateTail) and context takeover is implemented the
+// way real DEFLATE streams do it across a flush boundary: by priming the
+// new reader with a preset dictionary of the last maxWindowBits bytes
+// this direction has decompressed.
+type messageInflater struct {
+	noTakeover bool
+	dict       []byte
+}
+
+func newMessageInflater(noTakeover bool) *messageInflater {
+	return &messageInflater{noTakeover: noTakeover}
+}
+
+// inflate re-appends inflateTail (the trailing block stripped by
+// messageDeflater.deflate, plus a final block so flate.Reader terminates
+// cleanly) and inflates b back into its original form.
+func (d *messageInflater) inflate(b []byte) ([]byte, error) {
+	framed := io.MultiReader(bytes.NewReader(b), bytes.NewReader(inflateTail))
+
+	fr := flate.NewReaderDict(framed, d.dict)
+	defer fr.Close()
+
+	out, err := io.ReadAll(fr)
+	if err != nil {
+		return nil, err
+	}
+
+	if !d.noTakeover {
+		d.dict = append(d.dict, out...)
+		if len(d.dict) > maxWindowBits {
+			d.dict = append([]byte(nil), d.dict[len(d.dict)-maxWindowBits:]...)
+		}
+	}
+
+	return out, nil
+}
@@ -0,0 +1,127 @@
+package websocket
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/valyala/fasthttp"
+)
+
+// redundantMessage is long and repetitive enough that DEFLATE actually
+// shrinks it, so a peer that silently fell back to sending it
+// uncompressed would still be caught by the RSV1/payload checks below.
+const redundantMessage = "the quick brown fox jumps over the lazy dog. " +
+	"the quick brown fox jumps over the lazy dog. " +
+	"the quick brown fox jumps over the lazy dog."
+
+// TestCompressionInteropClientWithGorillaServer proves chunk0-1's
+// client-side permessage-deflate negotiation is wire-compatible with a
+// real peer, not just with itself: it dials a gorilla/websocket server
+// with compression enabled, sends a message, and checks the echoed
+// reply — a frame gorilla itself compressed — decompresses correctly.
+func TestCompressionInteropClientWithGorillaServer(t *testing.T) {
+	upgrader := websocket.Upgrader{EnableCompression: true}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(mt, data)
+	}))
+	defer srv.Close()
+
+	wsURL := "ws://" + strings.TrimPrefix(srv.URL, "http://") + "/ws"
+
+	client, err := DialWithConfig(wsURL, ClientConfig{
+		Origin:               srv.URL,
+		EnableCompression:    true,
+		CompressionThreshold: 0,
+	})
+	if err != nil {
+		t.Fatalf("DialWithConfig: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.WriteBinary([]byte(redundantMessage)); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	client.c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := client.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if !fr.Compressed() {
+		t.Fatalf("gorilla's echoed frame was not marked compressed")
+	}
+	if got := string(fr.Payload()); got != redundantMessage {
+		t.Fatalf("payload = %q, want %q", got, redundantMessage)
+	}
+}
+
+// TestCompressionInteropServerWithGorillaClient covers the other
+// direction: this package's server-side Upgrader (also added by
+// chunk0-1) negotiating permessage-deflate with a real
+// gorilla/websocket client, over an actual fasthttp listener.
+func TestCompressionInteropServerWithGorillaClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	upgr := &Upgrader{EnableCompression: true, CompressionThreshold: 0}
+
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		err := upgr.Upgrade(ctx, func(cl *Client) {
+			defer cl.Close()
+
+			fr := AcquireFrame()
+			defer ReleaseFrame(fr)
+
+			if _, err := cl.ReadFrame(fr); err != nil {
+				return
+			}
+			cl.WriteBinary(fr.Payload())
+		})
+		if err != nil {
+			ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+		}
+	})
+	defer ln.Close()
+
+	dialer := &websocket.Dialer{EnableCompression: true}
+	wsURL := "ws://" + ln.Addr().String() + "/ws"
+
+	conn, _, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("gorilla Dial: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte(redundantMessage)); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if got := string(data); got != redundantMessage {
+		t.Fatalf("payload = %q, want %q", got, redundantMessage)
+	}
+}
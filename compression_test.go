@@ -0,0 +1,266 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"testing"
+)
+
+// newCompressionPair wires up two *Client values over a net.Pipe with
+// permessage-deflate negotiated as chunk0-1 would after a real handshake,
+// exercising the wire format itself: masked frames, RSV1 only on a
+// message's first frame, and the trailing 0x00 0x00 0xff 0xff block
+// stripped/re-appended per RFC 7692 §7.2.1.
+//
+// This only checks the package against itself; compression_interop_test.go
+// covers wire compatibility against a real gorilla/websocket peer.
+func newCompressionPair(t *testing.T, serverNoTakeover, clientNoTakeover bool) (client, server *Client) {
+	t.Helper()
+
+	cConn, sConn := net.Pipe()
+
+	client = &Client{
+		c:   cConn,
+		brw: bufio.NewReadWriter(bufio.NewReader(cConn), bufio.NewWriter(cConn)),
+	}
+	client.deflater = newMessageDeflater(0, clientNoTakeover)
+	client.inflater = newMessageInflater(serverNoTakeover)
+
+	server = &Client{
+		c:   sConn,
+		brw: bufio.NewReadWriter(bufio.NewReader(sConn), bufio.NewWriter(sConn)),
+	}
+	server.deflater = newMessageDeflater(0, serverNoTakeover)
+	server.inflater = newMessageInflater(clientNoTakeover)
+
+	return client, server
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	client, server := newCompressionPair(t, false, false)
+
+	messages := []string{
+		"the quick brown fox jumps over the lazy dog",
+		"the quick brown fox jumps over the lazy dog again",
+		"a third, unrelated message to exercise the sliding window",
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		for _, msg := range messages {
+			if _, err := client.Write([]byte(msg)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	for _, want := range messages {
+		if _, err := server.ReadFrame(fr); err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		if !fr.Compressed() {
+			t.Fatalf("frame for %q was not marked compressed", want)
+		}
+		if got := string(fr.Payload()); got != want {
+			t.Fatalf("payload = %q, want %q", got, want)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+// TestCompressionWriteUncompressed proves WriteUncompressed/
+// WriteBinaryUncompressed actually bypass the negotiated deflater for a
+// single message, the per-message opt-out chunk0-1 asked for, rather
+// than compressing it regardless like Write/WriteBinary would.
+func TestCompressionWriteUncompressed(t *testing.T) {
+	client, server := newCompressionPair(t, false, false)
+
+	want := "the quick brown fox jumps over the lazy dog"
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.WriteBinaryUncompressed([]byte(want))
+		done <- err
+	}()
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	if _, err := server.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if fr.Compressed() {
+		t.Fatalf("frame was marked compressed despite WriteBinaryUncompressed")
+	}
+	if got := string(fr.Payload()); got != want {
+		t.Fatalf("payload = %q, want %q", got, want)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("WriteBinaryUncompressed: %v", err)
+	}
+}
+
+// TestCompressionNoContextTakeover proves the *_no_context_takeover
+// parameters are actually honored (not merely parsed and discarded): a
+// fresh flate.Writer/Reader pair is used per message, so the sliding
+// window never carries state into the next one.
+func TestCompressionNoContextTakeover(t *testing.T) {
+	client, server := newCompressionPair(t, true, true)
+
+	messages := []string{"hello, hello, hello", "goodbye, goodbye, goodbye"}
+
+	done := make(chan error, 1)
+	go func() {
+		for _, msg := range messages {
+			if _, err := client.Write([]byte(msg)); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	for _, want := range messages {
+		if _, err := server.ReadFrame(fr); err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		if got := string(fr.Payload()); got != want {
+			t.Fatalf("payload = %q, want %q", got, want)
+		}
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+// writeRawFrame writes fr directly over brw, bypassing Client.Write, so
+// tests can hand-assemble frames a real peer (e.g. gorilla/websocket)
+// might send, such as a message fragmented mid-DEFLATE-stream.
+func writeRawFrame(brw *bufio.ReadWriter, fr *Frame) error {
+	if _, err := fr.WriteTo(brw); err != nil {
+		return err
+	}
+	return brw.Flush()
+}
+
+// TestCompressionFragmentedMessageReassembled covers chunk0-1's
+// fragmentation handling: a compressed message split across multiple
+// frames, the way a real permessage-deflate peer fragments large
+// messages, must be reassembled and inflated as a whole rather than
+// rejected. RSV1 only marks the first frame; the continuation carries
+// the rest of the same DEFLATE stream.
+func TestCompressionFragmentedMessageReassembled(t *testing.T) {
+	client, server := newCompressionPair(t, false, false)
+
+	want := "the quick brown fox jumps over the lazy dog, fragmented across frames"
+	deflater := newMessageDeflater(0, false)
+	compressed, err := deflater.deflate([]byte(want))
+	if err != nil {
+		t.Fatalf("deflate: %v", err)
+	}
+	if len(compressed) < 2 {
+		t.Fatalf("compressed payload too short to split: %d bytes", len(compressed))
+	}
+	split := len(compressed) / 2
+
+	first := AcquireFrame()
+	first.SetBinary()
+	first.SetCompressed(true)
+	first.SetPayload(compressed[:split])
+	first.Mask()
+	defer ReleaseFrame(first)
+
+	cont := AcquireFrame()
+	cont.SetContinuation()
+	cont.SetFin()
+	cont.SetPayload(compressed[split:])
+	cont.Mask()
+	defer ReleaseFrame(cont)
+
+	// net.Pipe is unbuffered, so the writes must happen concurrently with
+	// ReadFrame rather than before it.
+	written := make(chan error, 1)
+	go func() {
+		if err := writeRawFrame(client.brw, first); err != nil {
+			written <- err
+			return
+		}
+		written <- writeRawFrame(client.brw, cont)
+	}()
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	if _, err := server.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if !fr.IsBinary() {
+		t.Fatalf("reassembled frame code = %v, want binary", fr.Code())
+	}
+	if !fr.Fin() {
+		t.Fatalf("reassembled frame is not marked FIN")
+	}
+	if got := string(fr.Payload()); got != want {
+		t.Fatalf("payload = %q, want %q", got, want)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("write frames: %v", err)
+	}
+}
+
+// TestCompressionContinuationRsv1Rejected covers the one fragmentation
+// case that is actually invalid per RFC 7692 §6.1: RSV1 set on a
+// continuation frame itself, rather than only on the first frame of the
+// message.
+func TestCompressionContinuationRsv1Rejected(t *testing.T) {
+	client, server := newCompressionPair(t, false, false)
+
+	first := AcquireFrame()
+	first.SetBinary()
+	first.SetPayload([]byte("partial"))
+	first.Mask()
+	defer ReleaseFrame(first)
+
+	cont := AcquireFrame()
+	cont.SetContinuation()
+	cont.SetFin()
+	cont.SetCompressed(true)
+	cont.SetPayload([]byte("rest"))
+	cont.Mask()
+	defer ReleaseFrame(cont)
+
+	written := make(chan error, 1)
+	go func() {
+		if err := writeRawFrame(client.brw, first); err != nil {
+			written <- err
+			return
+		}
+		written <- writeRawFrame(client.brw, cont)
+	}()
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	if _, err := server.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame (first fragment): %v", err)
+	}
+	if _, err := server.ReadFrame(fr); err != ErrCompressedFragmentation {
+		t.Fatalf("ReadFrame (continuation) error = %v, want ErrCompressedFragmentation", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("write frames: %v", err)
+	}
+}
@@ -0,0 +1,233 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	b64 "encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/valyala/fasthttp"
+)
+
+// ErrProxyConnect is returned when an HTTP proxy does not respond to the
+// CONNECT request with a 2xx status.
+var ErrProxyConnect = errors.New("websocket: proxy CONNECT failed")
+
+// DialContext establishes a websocket connection as a client, honoring
+// ctx cancellation while dialing, proxying, performing the TLS handshake
+// and reading the upgrade response.
+//
+// url must follow the WebSocket URL format i.e. ws://host:port/path. The
+// dial, TLS and proxy behaviour can be customized via ClientConfig's
+// NetDial, TLSConfig, Proxy and HandshakeTimeout fields.
+func DialContext(ctx context.Context, rawurl string, cconfig ClientConfig) (conn *Client, err error) {
+	uri := fasthttp.AcquireURI()
+	defer fasthttp.ReleaseURI(uri)
+
+	uri.Update(rawurl)
+
+	scheme, port := "http", ":80"
+	if !bytes.Equal(uri.Scheme(), wsString) {
+		scheme, port = "https", ":443"
+	}
+	uri.SetScheme(scheme)
+
+	addr := string(uri.Host())
+	if _, _, splitErr := net.SplitHostPort(addr); splitErr != nil {
+		addr += port
+	}
+
+	if cconfig.HandshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cconfig.HandshakeTimeout)
+		defer cancel()
+	}
+
+	c, err := dialContext(ctx, cconfig, scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	cconfig.using = true
+
+	conn, err = client(ctx, c, uri.String(), nil, cconfig)
+	if err != nil {
+		c.Close()
+	}
+
+	return conn, err
+}
+
+// dialContext resolves a proxy if one applies, dials the TCP connection
+// (through the proxy when present), and layers TLS on top for wss://.
+func dialContext(ctx context.Context, cconfig ClientConfig, scheme, addr string) (net.Conn, error) {
+	proxyURL, err := resolveProxy(cconfig, scheme, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	netDial := cconfig.NetDial
+	if netDial == nil {
+		var d net.Dialer
+		netDial = d.DialContext
+	}
+
+	dialAddr := addr
+	if proxyURL != nil {
+		dialAddr = proxyAddr(proxyURL)
+	}
+
+	c, err := netDial(ctx, "tcp", dialAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyURL != nil {
+		if c, err = connectThroughProxy(ctx, c, proxyURL, addr); err != nil {
+			return nil, err
+		}
+	}
+
+	if scheme == "https" {
+		tlsConfig := cconfig.TLSConfig
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{
+				MinVersion: tls.VersionTLS11,
+				MaxVersion: tls.VersionTLS13,
+			}
+		} else {
+			tlsConfig = tlsConfig.Clone()
+		}
+
+		// Unlike tls.Dial, tls.Client does not derive ServerName from the
+		// dial address, so certificate verification would otherwise run
+		// against an empty ServerName and fail for every wss:// dial.
+		if tlsConfig.ServerName == "" {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				tlsConfig.ServerName = host
+			} else {
+				tlsConfig.ServerName = addr
+			}
+		}
+
+		tc := tls.Client(c, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tc
+	}
+
+	return c, nil
+}
+
+// resolveProxy returns the proxy to dial through for addr, using
+// cconfig.Proxy if set or http.ProxyFromEnvironment otherwise. A nil
+// result means connect directly.
+func resolveProxy(cconfig ClientConfig, scheme, addr string) (*url.URL, error) {
+	proxyFn := cconfig.Proxy
+	if proxyFn == nil {
+		// http.ProxyFromEnvironment takes an *http.Request, not a
+		// *url.URL like ClientConfig.Proxy; only its URL field is
+		// consulted, so a bare request wrapping addr is enough.
+		proxyFn = func(u *url.URL) (*url.URL, error) {
+			return http.ProxyFromEnvironment(&http.Request{URL: u})
+		}
+	}
+
+	return proxyFn(&url.URL{Scheme: scheme, Host: addr})
+}
+
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return u.Host + ":80"
+}
+
+// connectThroughProxy issues an HTTP CONNECT request for targetAddr over
+// c and, on success, returns a net.Conn positioned right after the
+// proxy's response so the TLS handshake or WebSocket upgrade can proceed.
+func connectThroughProxy(ctx context.Context, c net.Conn, proxyURL *url.URL, targetAddr string) (net.Conn, error) {
+	var req bytes.Buffer
+	fmt.Fprintf(&req, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n", targetAddr, targetAddr)
+
+	if user := proxyURL.User; user != nil {
+		fmt.Fprintf(&req, "Proxy-Authorization: Basic %s\r\n", basicAuth(user))
+	}
+
+	req.WriteString("\r\n")
+
+	done := make(chan error, 1)
+	go func() {
+		_, werr := c.Write(req.Bytes())
+		done <- werr
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+
+	br := bufio.NewReader(c)
+
+	type connectResponse struct {
+		res *http.Response
+		err error
+	}
+
+	respCh := make(chan connectResponse, 1)
+	go func() {
+		res, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+		respCh <- connectResponse{res, err}
+	}()
+
+	var resp connectResponse
+	select {
+	case resp = <-respCh:
+	case <-ctx.Done():
+		c.Close()
+		return nil, ctx.Err()
+	}
+
+	if resp.err != nil {
+		c.Close()
+		return nil, resp.err
+	}
+	resp.res.Body.Close()
+
+	if resp.res.StatusCode < 200 || resp.res.StatusCode > 299 {
+		c.Close()
+		return nil, ErrProxyConnect
+	}
+
+	return &bufferedConn{Conn: c, br: br}, nil
+}
+
+func basicAuth(u *url.Userinfo) string {
+	password, _ := u.Password()
+	return b64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+}
+
+// bufferedConn is a net.Conn whose Read is served from a bufio.Reader
+// that may already hold bytes read past an HTTP CONNECT response.
+type bufferedConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (bc *bufferedConn) Read(p []byte) (int, error) {
+	return bc.br.Read(p)
+}
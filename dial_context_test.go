@@ -0,0 +1,289 @@
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// startEchoServer runs a real WebSocket server via Upgrader/fasthttp.Serve
+// that echoes back whatever binary message it receives, so DialContext
+// can be exercised end to end against a real handshake and frame
+// round trip rather than just its dialContext/connectThroughProxy
+// helpers.
+func startEchoServer(t *testing.T) (addr string, close func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	upgr := &Upgrader{}
+	go fasthttp.Serve(ln, func(ctx *fasthttp.RequestCtx) {
+		upgr.Upgrade(ctx, func(cl *Client) {
+			defer cl.Close()
+
+			fr := AcquireFrame()
+			defer ReleaseFrame(fr)
+
+			if _, err := cl.ReadFrame(fr); err != nil {
+				return
+			}
+			cl.WriteBinary(fr.Payload())
+		})
+	})
+
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// startTunnelingProxy runs a real HTTP CONNECT proxy on loopback that
+// dials backendAddr and pipes bytes in both directions after accepting
+// the tunnel, unlike startFakeProxy's fixed banner, so DialContext's
+// WebSocket handshake and frame traffic can actually flow through it.
+func startTunnelingProxy(t *testing.T) (addr string, close func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				br := bufio.NewReader(c)
+				req, err := http.ReadRequest(br)
+				if err != nil {
+					c.Close()
+					return
+				}
+
+				backend, err := net.Dial("tcp", req.Host)
+				if err != nil {
+					c.Close()
+					return
+				}
+
+				c.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+				go func() {
+					io.Copy(backend, br)
+					backend.Close()
+				}()
+				io.Copy(c, backend)
+				c.Close()
+			}(c)
+		}
+	}()
+
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+// startFakeProxy runs a minimal HTTP CONNECT proxy on loopback that
+// always accepts the tunnel and then echoes back a fixed banner, so
+// connectThroughProxy can be exercised against a real TCP connection
+// rather than a mock.
+func startFakeProxy(t *testing.T, banner string) (addr string, close func()) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn) {
+				defer c.Close()
+
+				br := bufio.NewReader(c)
+				if _, err := http.ReadRequest(br); err != nil {
+					return
+				}
+
+				c.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+				c.Write([]byte(banner))
+
+				// Keep the connection open briefly so the client has a
+				// chance to read the banner before the test tears down.
+				time.Sleep(50 * time.Millisecond)
+			}(c)
+		}
+	}()
+
+	return ln.Addr().String(), func() { ln.Close() }
+}
+
+func TestConnectThroughProxy(t *testing.T) {
+	proxyAddr, closeProxy := startFakeProxy(t, "post-connect data")
+	defer closeProxy()
+
+	c, err := net.Dial("tcp", proxyAddr)
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+
+	proxyURL := &url.URL{Scheme: "http", Host: proxyAddr}
+	tunneled, err := connectThroughProxy(context.Background(), c, proxyURL, "backend.example:443")
+	if err != nil {
+		t.Fatalf("connectThroughProxy: %v", err)
+	}
+	defer tunneled.Close()
+
+	buf := make([]byte, len("post-connect data"))
+	if _, err := tunneled.Read(buf); err != nil {
+		t.Fatalf("read post-CONNECT data: %v", err)
+	}
+	if string(buf) != "post-connect data" {
+		t.Fatalf("post-CONNECT data = %q, want %q", buf, "post-connect data")
+	}
+}
+
+// TestDialContextDirect calls the exported DialContext entry point
+// itself (not just its dialContext/connectThroughProxy helpers) against
+// a real listener with no proxy involved, proving it actually wires the
+// dial, the handshake and cconfig.using/origin handling together into a
+// working *Client.
+func TestDialContextDirect(t *testing.T) {
+	addr, closeSrv := startEchoServer(t)
+	defer closeSrv()
+
+	conn, err := DialContext(context.Background(), "ws://"+addr+"/ws", ClientConfig{})
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+
+	want := "hello through DialContext"
+	if _, err := conn.WriteBinary([]byte(want)); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	if _, err := conn.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if got := string(fr.Payload()); got != want {
+		t.Fatalf("payload = %q, want %q", got, want)
+	}
+}
+
+// TestDialContextThroughProxy calls DialContext with ClientConfig.Proxy
+// pointed at a real CONNECT proxy in front of a real WebSocket server,
+// proving the exported entry point's proxy wiring (not just
+// connectThroughProxy in isolation) produces a working *Client.
+func TestDialContextThroughProxy(t *testing.T) {
+	backendAddr, closeSrv := startEchoServer(t)
+	defer closeSrv()
+
+	proxyAddr, closeProxy := startTunnelingProxy(t)
+	defer closeProxy()
+
+	cconfig := ClientConfig{
+		Proxy: func(*url.URL) (*url.URL, error) {
+			return &url.URL{Scheme: "http", Host: proxyAddr}, nil
+		},
+	}
+
+	conn, err := DialContext(context.Background(), "ws://"+backendAddr+"/ws", cconfig)
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	defer conn.Close()
+
+	want := "hello through the proxy"
+	if _, err := conn.WriteBinary([]byte(want)); err != nil {
+		t.Fatalf("WriteBinary: %v", err)
+	}
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	if _, err := conn.ReadFrame(fr); err != nil {
+		t.Fatalf("ReadFrame: %v", err)
+	}
+	if got := string(fr.Payload()); got != want {
+		t.Fatalf("payload = %q, want %q", got, want)
+	}
+}
+
+// TestConnectThroughProxyContextCancel proves the proxy response read
+// honors ctx: a proxy that accepts the TCP connection but never replies
+// must not hang connectThroughProxy past cancellation.
+func TestConnectThroughProxyContextCancel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		// Accept the connection but never respond to the CONNECT.
+		time.Sleep(time.Second)
+	}()
+
+	c, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial proxy: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	proxyURL := &url.URL{Scheme: "http", Host: ln.Addr().String()}
+
+	start := time.Now()
+	_, err = connectThroughProxy(ctx, c, proxyURL, "backend.example:443")
+	if err == nil {
+		t.Fatal("expected an error from a proxy that never responds")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("connectThroughProxy took %v, want it to return promptly on ctx cancellation", elapsed)
+	}
+}
+
+// TestDialContextTLSServerName proves dialContext sets ServerName for
+// wss:// dials: httptest.NewTLSServer's certificate is only valid for
+// 127.0.0.1, so the handshake only succeeds if tls.Client is configured
+// with that host as ServerName rather than the empty default.
+func TestDialContextTLSServerName(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	addr := srv.Listener.Addr().String()
+
+	cconfig := ClientConfig{
+		TLSConfig: &tls.Config{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs},
+	}
+
+	conn, err := dialContext(context.Background(), cconfig, "https", addr)
+	if err != nil {
+		t.Fatalf("dialContext: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,423 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// StatusCode is the close status code carried on a close frame's payload,
+// per RFC 6455 §7.4.
+type StatusCode uint16
+
+const (
+	// StatusNone is sent when the peer doesn't need to convey any
+	// particular close reason.
+	StatusNone StatusCode = 1000
+	// StatusGoingAway is sent when an endpoint (e.g. a server going down
+	// or a browser navigating away) is leaving.
+	StatusGoingAway StatusCode = 1001
+	// StatusProtocolError is sent when a peer violated the protocol.
+	StatusProtocolError StatusCode = 1002
+	// StatusUnsupportedData is sent when an endpoint received a data
+	// type it cannot accept (e.g. binary-only received text).
+	StatusUnsupportedData StatusCode = 1003
+	// StatusInvalidFramePayloadData is sent when a message's payload
+	// doesn't match its type (e.g. non-UTF-8 text).
+	StatusInvalidFramePayloadData StatusCode = 1007
+	// StatusPolicyViolation is sent when a message violates an
+	// endpoint's policy and no more specific status applies.
+	StatusPolicyViolation StatusCode = 1008
+	// StatusMessageTooBig is sent when a message is too large to
+	// process.
+	StatusMessageTooBig StatusCode = 1009
+	// StatusMandatoryExtension is sent by a client when the server
+	// failed to negotiate an extension the client required.
+	StatusMandatoryExtension StatusCode = 1010
+	// StatusInternalError is sent when an endpoint encountered an
+	// unexpected condition that prevented it from fulfilling the
+	// request, as EnableKeepalive does when a pong times out.
+	StatusInternalError StatusCode = 1011
+)
+
+// Code identifies a frame's opcode, per RFC 6455 §5.2.
+type Code uint8
+
+const (
+	// CodeContinuation marks a frame as a continuation of a previous
+	// fragmented message.
+	CodeContinuation Code = 0x0
+	// CodeText marks a frame as carrying UTF-8 text.
+	CodeText Code = 0x1
+	// CodeBinary marks a frame as carrying binary data.
+	CodeBinary Code = 0x2
+	// CodeClose marks a frame as a connection close request.
+	CodeClose Code = 0x8
+	// CodePing marks a frame as a keepalive ping.
+	CodePing Code = 0x9
+	// CodePong marks a frame as a keepalive pong.
+	CodePong Code = 0xA
+)
+
+const (
+	finBit  = byte(1 << 7)
+	rsv1Bit = byte(1 << 6)
+	maskBit = byte(1 << 7)
+
+	// opSize is the largest a frame's leading header (the 2 base bytes
+	// plus the widest possible extended length) can be.
+	opSize     = 10
+	maskSize   = 4
+	statusSize = 2
+
+	// DefaultPayloadSize bounds how large a single frame's payload may
+	// be before ReadFrom rejects it. Frame exposes no way to change it.
+	DefaultPayloadSize = 1 << 20
+
+	// limitLen is an absolute ceiling on the declared frame length,
+	// independent of max, so a corrupt/hostile header can't make
+	// ReadFrom try to allocate an enormous buffer.
+	limitLen = 1 << 32
+)
+
+var zeroBytes = make([]byte, opSize)
+
+// Frame is the unit exchanged between endpoints over a WebSocket
+// connection: a header (fin/rsv/opcode/mask/length bits), an optional
+// mask key, an optional close status and a payload.
+type Frame struct {
+	max    uint64
+	op     []byte
+	mask   []byte
+	status []byte
+	b      []byte
+}
+
+var framePool = sync.Pool{
+	New: func() interface{} {
+		return &Frame{
+			max:    DefaultPayloadSize,
+			op:     make([]byte, opSize),
+			mask:   make([]byte, maskSize),
+			status: make([]byte, statusSize),
+			b:      make([]byte, 0, 128),
+		}
+	},
+}
+
+// AcquireFrame gets a Frame from the package pool. Callers must return it
+// with ReleaseFrame once done.
+func AcquireFrame() *Frame {
+	return framePool.Get().(*Frame)
+}
+
+// ReleaseFrame resets fr and returns it to the package pool.
+func ReleaseFrame(fr *Frame) {
+	fr.Reset()
+	framePool.Put(fr)
+}
+
+// Reset clears fr's header and payload so it can be reused for an
+// unrelated frame.
+func (fr *Frame) Reset() {
+	copy(fr.op, zeroBytes)
+	copy(fr.mask, zeroBytes)
+	copy(fr.status, zeroBytes)
+	fr.b = fr.b[:0]
+}
+
+// Fin reports whether the FIN bit is set, i.e. fr is the final frame of
+// its message.
+func (fr *Frame) Fin() bool {
+	return fr.op[0]&finBit != 0
+}
+
+// SetFin sets the FIN bit.
+func (fr *Frame) SetFin() {
+	fr.op[0] |= finBit
+}
+
+// Rsv1 reports whether the RSV1 bit is set.
+func (fr *Frame) Rsv1() bool {
+	return fr.op[0]&rsv1Bit != 0
+}
+
+// SetRsv1 sets or clears the RSV1 bit.
+func (fr *Frame) SetRsv1(b bool) {
+	if b {
+		fr.op[0] |= rsv1Bit
+	} else {
+		fr.op[0] &^= rsv1Bit
+	}
+}
+
+// Code returns fr's opcode.
+func (fr *Frame) Code() Code {
+	return Code(fr.op[0] & 0x0f)
+}
+
+// SetCode sets fr's opcode.
+func (fr *Frame) SetCode(code Code) {
+	fr.op[0] &^= 0x0f
+	fr.op[0] |= uint8(code) & 0x0f
+}
+
+// SetContinuation sets CodeContinuation as fr's opcode.
+func (fr *Frame) SetContinuation() { fr.SetCode(CodeContinuation) }
+
+// SetText sets CodeText as fr's opcode.
+func (fr *Frame) SetText() { fr.SetCode(CodeText) }
+
+// SetBinary sets CodeBinary as fr's opcode.
+func (fr *Frame) SetBinary() { fr.SetCode(CodeBinary) }
+
+// SetClose sets CodeClose as fr's opcode.
+func (fr *Frame) SetClose() { fr.SetCode(CodeClose) }
+
+// SetPing sets CodePing as fr's opcode.
+func (fr *Frame) SetPing() { fr.SetCode(CodePing) }
+
+// SetPong sets CodePong as fr's opcode.
+func (fr *Frame) SetPong() { fr.SetCode(CodePong) }
+
+// IsContinuation reports whether fr's opcode is CodeContinuation.
+func (fr *Frame) IsContinuation() bool { return fr.Code() == CodeContinuation }
+
+// IsBinary reports whether fr's opcode is CodeBinary.
+func (fr *Frame) IsBinary() bool { return fr.Code() == CodeBinary }
+
+// IsClose reports whether fr's opcode is CodeClose.
+func (fr *Frame) IsClose() bool { return fr.Code() == CodeClose }
+
+// IsPing reports whether fr's opcode is CodePing.
+func (fr *Frame) IsPing() bool { return fr.Code() == CodePing }
+
+// IsPong reports whether fr's opcode is CodePong.
+func (fr *Frame) IsPong() bool { return fr.Code() == CodePong }
+
+// IsMasked reports whether fr's MASK bit is set.
+func (fr *Frame) IsMasked() bool {
+	return fr.op[1]&maskBit != 0
+}
+
+// MaskKey returns fr's 4-byte mask key: zero-filled for a frame fresh
+// from AcquireFrame, otherwise the key last generated by Mask or read by
+// ReadFrom. Unmask clears the MASK bit but leaves the key in place.
+func (fr *Frame) MaskKey() []byte {
+	return fr.mask[:4]
+}
+
+// Len returns the payload length declared in fr's header.
+func (fr *Frame) Len() uint64 {
+	n := uint64(fr.op[1] & 0x7f)
+	switch n {
+	case 126:
+		n = uint64(binary.BigEndian.Uint16(fr.op[2:]))
+	case 127:
+		n = binary.BigEndian.Uint64(fr.op[2:])
+	}
+	return n
+}
+
+func (fr *Frame) setLength(n int) {
+	switch {
+	case n > 65535:
+		fr.op[1] |= 127
+		binary.BigEndian.PutUint64(fr.op[2:], uint64(n))
+	case n > 125:
+		fr.op[1] |= 126
+		binary.BigEndian.PutUint16(fr.op[2:], uint16(n))
+	default:
+		fr.op[1] |= uint8(n)
+	}
+}
+
+// mustRead returns how many extra header bytes, beyond the base 2, must
+// be read to learn fr's payload length.
+func (fr *Frame) mustRead() int {
+	switch fr.op[1] & 0x7f {
+	case 127:
+		return 8
+	case 126:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func (fr *Frame) hasStatus() bool {
+	return fr.status[0] != 0 || fr.status[1] != 0
+}
+
+// Payload returns fr's payload.
+func (fr *Frame) Payload() []byte {
+	return fr.b
+}
+
+// SetPayload replaces fr's payload with a copy of b.
+func (fr *Frame) SetPayload(b []byte) {
+	fr.b = append(fr.b[:0], b...)
+}
+
+// Status returns the close status carried by fr's payload.
+//
+// It is only meaningful for a close frame (IsClose), after ReadFrom has
+// split the status out of the payload.
+func (fr *Frame) Status() StatusCode {
+	return StatusCode(binary.BigEndian.Uint16(fr.status))
+}
+
+// SetStatus sets the close status to be written with fr's payload. It is
+// only meaningful for a close frame (SetClose).
+func (fr *Frame) SetStatus(status StatusCode) {
+	binary.BigEndian.PutUint16(fr.status, uint16(status))
+}
+
+// Mask masks fr's payload with a freshly generated key and sets the MASK
+// bit, as RFC 6455 §5.3 requires of every client-to-server frame.
+func (fr *Frame) Mask() {
+	fr.op[1] |= maskBit
+	readMask(fr.mask)
+	if len(fr.b) > 0 {
+		mask(fr.mask, fr.b)
+	}
+}
+
+// Unmask undoes Mask, restoring fr's payload to its original bytes and
+// clearing the MASK bit.
+func (fr *Frame) Unmask() {
+	if len(fr.b) > 0 {
+		mask(fr.MaskKey(), fr.b)
+	}
+	fr.op[1] &^= maskBit
+}
+
+// WriteTo writes fr's header, mask key, status (if any) and payload to
+// wr, per RFC 6455 §5.2.
+func (fr *Frame) WriteTo(wr io.Writer) (int64, error) {
+	var n int64
+
+	payloadLen := len(fr.b)
+	if fr.hasStatus() {
+		payloadLen += statusSize
+	}
+	fr.setLength(payloadLen)
+
+	headerLen := 2 + fr.mustRead()
+	ni, err := wr.Write(fr.op[:headerLen])
+	n += int64(ni)
+	if err != nil {
+		return n, err
+	}
+
+	if fr.IsMasked() {
+		ni, err = wr.Write(fr.mask)
+		n += int64(ni)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if fr.hasStatus() {
+		ni, err = wr.Write(fr.status)
+		n += int64(ni)
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if len(fr.b) > 0 {
+		ni, err = wr.Write(fr.b)
+		n += int64(ni)
+	}
+
+	return n, err
+}
+
+var (
+	errReadingHeader = errors.New("websocket: error reading frame header")
+	errReadingLen    = errors.New("websocket: error reading payload length")
+	errReadingMask   = errors.New("websocket: error reading mask key")
+	errLenTooBig     = errors.New("websocket: frame length exceeds the configured maximum")
+	errStatusLen     = errors.New("websocket: close frame payload shorter than a status code")
+)
+
+// ReadFrom reads a single frame from rd into fr, per RFC 6455 §5.2. A
+// close frame's leading 2 status bytes are split out into fr.status, so
+// Payload returns only the application data that follows them.
+func (fr *Frame) ReadFrom(rd io.Reader) (int64, error) {
+	var n int64
+
+	ni, err := io.ReadFull(rd, fr.op[:2])
+	n += int64(ni)
+	if err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errReadingHeader
+		}
+		return n, err
+	}
+
+	if m := fr.mustRead(); m > 0 {
+		ni, err = io.ReadFull(rd, fr.op[2:2+m])
+		n += int64(ni)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errReadingLen
+			}
+			return n, err
+		}
+	}
+
+	if fr.IsMasked() {
+		ni, err = io.ReadFull(rd, fr.mask)
+		n += int64(ni)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errReadingMask
+			}
+			return n, err
+		}
+	}
+
+	payloadLen := fr.Len()
+	if payloadLen == 0 {
+		fr.b = fr.b[:0]
+		return n, nil
+	}
+
+	if (fr.max > 0 && payloadLen > fr.max) || payloadLen > limitLen {
+		return n, errLenTooBig
+	}
+
+	want := int64(payloadLen)
+	if fr.IsClose() {
+		want -= statusSize
+		if want < 0 {
+			return n, errStatusLen
+		}
+
+		ni, err = io.ReadFull(rd, fr.status)
+		n += int64(ni)
+		if err != nil {
+			if err == io.ErrUnexpectedEOF {
+				err = errStatusLen
+			}
+			return n, err
+		}
+	}
+
+	if cap(fr.b) < int(want) {
+		fr.b = append(fr.b[:cap(fr.b)], make([]byte, int(want)-cap(fr.b))...)
+	}
+	fr.b = fr.b[:want]
+
+	ni, err = io.ReadFull(rd, fr.b)
+	n += int64(ni)
+
+	if fr.IsMasked() {
+		fr.Unmask()
+	}
+
+	return n, err
+}
@@ -0,0 +1,22 @@
+package websocket
+
+// SetCompressed marks the frame as carrying a permessage-deflate payload
+// by setting the RSV1 bit, as required by RFC 7692 §6. Only the first
+// frame of a fragmented message should carry this bit.
+//
+// SetCompressed only sets the bit; it does not deflate fr's payload.
+// WriteFrame sends fr exactly as given, so a caller assembling its own
+// frame is responsible for running the negotiated deflater (or whatever
+// produced the payload) before calling SetCompressed(true). To have a
+// message's compression handled automatically, use Write/WriteBinary
+// (or opt a specific message out with WriteUncompressed/
+// WriteBinaryUncompressed) instead of building a Frame by hand.
+func (fr *Frame) SetCompressed(b bool) {
+	fr.SetRsv1(b)
+}
+
+// Compressed reports whether the frame's RSV1 bit is set, indicating a
+// permessage-deflate payload per RFC 7692 §6.
+func (fr *Frame) Compressed() bool {
+	return fr.Rsv1()
+}
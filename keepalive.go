@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidPingInterval is returned by EnableKeepalive when
+// cfg.PingInterval is not positive; time.NewTicker would otherwise panic.
+var ErrInvalidPingInterval = errors.New("websocket: PingInterval must be positive")
+
+// KeepaliveConfig configures Client.EnableKeepalive.
+type KeepaliveConfig struct {
+	// PingInterval is how often a ping frame is sent to the peer.
+	PingInterval time.Duration
+
+	// PongTimeout is how long to wait for a pong, counted from the ping
+	// that should have elicited it, before the connection is considered
+	// dead and closed with status 1011 (internal error).
+	PongTimeout time.Duration
+
+	// WriteTimeout, if non-zero, is applied as a write deadline before
+	// every keepalive ping.
+	WriteTimeout time.Duration
+
+	// ReadTimeout, if non-zero, is applied as the connection's read
+	// deadline and refreshed every time a pong is received.
+	ReadTimeout time.Duration
+}
+
+// EnableKeepalive starts a background goroutine that pings the peer every
+// cfg.PingInterval and closes the connection with status 1011 if no pong
+// is observed within cfg.PongTimeout of the last ping. It is opt-in and
+// should be called once per Client, after the handshake completes.
+//
+// It returns ErrInvalidPingInterval, without starting the goroutine, if
+// cfg.PingInterval is not positive.
+func (c *Client) EnableKeepalive(cfg KeepaliveConfig) error {
+	if cfg.PingInterval <= 0 {
+		return ErrInvalidPingInterval
+	}
+
+	c.handlerMu.Lock()
+	c.keepalive = cfg
+	c.handlerMu.Unlock()
+
+	c.pongMu.Lock()
+	c.lastPong = time.Now()
+	c.pongMu.Unlock()
+
+	if cfg.ReadTimeout > 0 {
+		c.c.SetReadDeadline(time.Now().Add(cfg.ReadTimeout))
+	}
+
+	go c.keepaliveLoop(cfg)
+
+	return nil
+}
+
+func (c *Client) keepaliveLoop(cfg KeepaliveConfig) {
+	ticker := time.NewTicker(cfg.PingInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		c.pongMu.Lock()
+		last := c.lastPong
+		c.pongMu.Unlock()
+
+		if cfg.PongTimeout > 0 && time.Since(last) > cfg.PingInterval+cfg.PongTimeout {
+			closeFr := AcquireFrame()
+			closeFr.SetFin()
+			closeFr.SetClose()
+			closeFr.SetStatus(StatusInternalError) // RFC 6455 §7.4.1
+			c.maskOutgoing(closeFr)
+
+			c.WriteFrame(closeFr)
+			ReleaseFrame(closeFr)
+			c.c.Close()
+
+			return
+		}
+
+		if cfg.WriteTimeout > 0 {
+			c.c.SetWriteDeadline(time.Now().Add(cfg.WriteTimeout))
+		}
+
+		fr := AcquireFrame()
+		fr.SetFin()
+		fr.SetPing()
+		c.maskOutgoing(fr)
+
+		_, err := c.WriteFrame(fr)
+		ReleaseFrame(fr)
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// SetPingHandler sets the function called when a ping frame is received.
+// It replaces the default behaviour, which is to reply with a pong
+// carrying the same application data. It may be called concurrently with
+// ReadFrame.
+func (c *Client) SetPingHandler(h func(appData []byte)) {
+	c.handlerMu.Lock()
+	c.pingHandler = h
+	c.handlerMu.Unlock()
+}
+
+// SetPongHandler sets the function called when a pong frame is received,
+// in addition to EnableKeepalive's own liveness bookkeeping. It may be
+// called concurrently with ReadFrame.
+func (c *Client) SetPongHandler(h func(appData []byte)) {
+	c.handlerMu.Lock()
+	c.pongHandler = h
+	c.handlerMu.Unlock()
+}
+
+func (c *Client) handlePing(fr *Frame) {
+	appData := append([]byte(nil), fr.Payload()...)
+
+	c.handlerMu.Lock()
+	h := c.pingHandler
+	c.handlerMu.Unlock()
+
+	if h != nil {
+		h(appData)
+		return
+	}
+
+	pong := AcquireFrame()
+	defer ReleaseFrame(pong)
+
+	pong.SetFin()
+	pong.SetPong()
+	pong.SetPayload(appData)
+	c.maskOutgoing(pong)
+
+	c.WriteFrame(pong)
+}
+
+func (c *Client) handlePong(fr *Frame) {
+	appData := append([]byte(nil), fr.Payload()...)
+
+	c.pongMu.Lock()
+	c.lastPong = time.Now()
+	c.pongMu.Unlock()
+
+	c.handlerMu.Lock()
+	readTimeout := c.keepalive.ReadTimeout
+	h := c.pongHandler
+	c.handlerMu.Unlock()
+
+	if readTimeout > 0 {
+		c.c.SetReadDeadline(time.Now().Add(readTimeout))
+	}
+
+	if h != nil {
+		h(appData)
+	}
+}
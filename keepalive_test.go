@@ -0,0 +1,136 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func newKeepalivePair(t *testing.T) (client, peer *Client) {
+	t.Helper()
+
+	cConn, pConn := net.Pipe()
+	t.Cleanup(func() { cConn.Close(); pConn.Close() })
+
+	client = &Client{c: cConn, brw: bufio.NewReadWriter(bufio.NewReader(cConn), bufio.NewWriter(cConn))}
+	peer = &Client{c: pConn, brw: bufio.NewReadWriter(bufio.NewReader(pConn), bufio.NewWriter(pConn))}
+
+	return client, peer
+}
+
+// TestKeepalivePing proves EnableKeepalive actually pings the peer on
+// PingInterval. ReadFrame handles ping frames internally and never
+// returns them to the caller, so the ping is observed via
+// SetPingHandler, with a background ReadFrame loop running it.
+func TestKeepalivePing(t *testing.T) {
+	client, peer := newKeepalivePair(t)
+
+	pinged := make(chan struct{}, 1)
+	peer.SetPingHandler(func(appData []byte) {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+	})
+
+	go func() {
+		fr := AcquireFrame()
+		defer ReleaseFrame(fr)
+		peer.ReadFrame(fr)
+	}()
+
+	client.EnableKeepalive(KeepaliveConfig{PingInterval: 10 * time.Millisecond})
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		t.Fatal("expected a ping frame from the keepalive loop")
+	}
+}
+
+// TestKeepaliveTimeoutClosesConnection proves a missing pong within
+// PongTimeout closes the connection, rather than pinging forever.
+// ReadFrame handles the pings it sees internally and never returns them,
+// so the first frame it does return to the caller is the close frame
+// the keepalive loop sends once it gives up.
+func TestKeepaliveTimeoutClosesConnection(t *testing.T) {
+	client, peer := newKeepalivePair(t)
+
+	client.EnableKeepalive(KeepaliveConfig{
+		PingInterval: 5 * time.Millisecond,
+		PongTimeout:  5 * time.Millisecond,
+	})
+
+	// peer.ReadFrame's default ping handling auto-replies with a pong,
+	// which would defeat this test by keeping the client alive; swallow
+	// pings instead so the client is actually starved of a pong.
+	peer.SetPingHandler(func(appData []byte) {})
+
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := peer.ReadFrame(fr)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil && !fr.IsClose() {
+			t.Fatalf("got a %v frame, want a close frame or a read error", fr)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("keepalive did not close the connection after the pong timeout")
+	}
+}
+
+// TestEnableKeepaliveRejectsZeroPingInterval guards against the
+// time.NewTicker panic a zero PingInterval (e.g. a caller who only set
+// PongTimeout) would otherwise trigger in keepaliveLoop.
+func TestEnableKeepaliveRejectsZeroPingInterval(t *testing.T) {
+	client, _ := newKeepalivePair(t)
+
+	if err := client.EnableKeepalive(KeepaliveConfig{PongTimeout: time.Second}); err != ErrInvalidPingInterval {
+		t.Fatalf("EnableKeepalive error = %v, want ErrInvalidPingInterval", err)
+	}
+}
+
+// TestSetPongHandlerConcurrentWithReadFrame guards against the data race
+// EnableKeepalive/SetPongHandler and ReadFrame's handling of an
+// in-flight pong used to have: run with -race to catch a regression.
+func TestSetPongHandlerConcurrentWithReadFrame(t *testing.T) {
+	client, peer := newKeepalivePair(t)
+
+	called := make(chan []byte, 1)
+	client.SetPongHandler(func(appData []byte) { called <- appData })
+
+	go func() {
+		pong := AcquireFrame()
+		defer ReleaseFrame(pong)
+		pong.SetFin()
+		pong.SetPong()
+		pong.SetPayload([]byte("pong"))
+		pong.Mask()
+		pong.WriteTo(peer.brw)
+		peer.brw.Flush()
+	}()
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+		fr := AcquireFrame()
+		defer ReleaseFrame(fr)
+		client.ReadFrame(fr)
+	}()
+
+	client.EnableKeepalive(KeepaliveConfig{PingInterval: time.Hour, ReadTimeout: time.Minute})
+
+	select {
+	case <-called:
+	case <-readDone:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the pong to be observed")
+	}
+}
@@ -0,0 +1,15 @@
+package websocket
+
+import "crypto/rand"
+
+// mask XORs b in place against the rolling 4-byte key, per RFC 6455 §5.3.
+func mask(key, b []byte) {
+	for i := range b {
+		b[i] ^= key[i&3]
+	}
+}
+
+// readMask fills key with fresh random bytes for Frame.Mask to use.
+func readMask(key []byte) {
+	rand.Read(key)
+}
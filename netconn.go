@@ -0,0 +1,147 @@
+package websocket
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// NetConn returns a net.Conn backed by c's binary message stream: each
+// Write sends one binary data frame, and Read pulls from a buffered
+// stream of incoming binary frames, with continuation frames
+// concatenated and control frames (ping/pong, handled by ReadFrame)
+// processed transparently in the background. This lets net.Conn-oriented
+// code (net/rpc, gRPC, *tls.Conn, an SSH client) run on top of a
+// WebSocket connection without writing its own framing shim.
+func (c *Client) NetConn() net.Conn {
+	wc := &wsConn{c: c}
+	wc.cond = sync.NewCond(&wc.mu)
+
+	go wc.readLoop()
+
+	return wc
+}
+
+// wsConn adapts a *Client's message stream to the net.Conn interface.
+// Incoming payloads are buffered (rather than handed off via an io.Pipe,
+// whose Write blocks until a reader drains it) so that a slow or absent
+// Read caller can't stall readLoop — and, with it, ReadFrame's ping/pong
+// keepalive handling on the shared connection. This mirrors the
+// buffered-delivery pattern channelStream uses for the same reason.
+type wsConn struct {
+	c *Client
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	buf    bytes.Buffer
+	closed bool
+	err    error
+}
+
+func (wc *wsConn) readLoop() {
+	fr := AcquireFrame()
+	defer ReleaseFrame(fr)
+
+	// inBinary is true while a fragmented binary message is being read,
+	// so that only its continuation frames are delivered.
+	inBinary := false
+
+	for {
+		if _, err := wc.c.ReadFrame(fr); err != nil {
+			wc.closeWithError(err)
+			return
+		}
+
+		if fr.IsClose() {
+			wc.closeWithError(io.EOF)
+			return
+		}
+
+		switch {
+		case fr.IsBinary(), fr.IsContinuation() && inBinary:
+			inBinary = !fr.Fin()
+		default:
+			continue
+		}
+
+		wc.deliver(fr.Payload())
+	}
+}
+
+// deliver appends data read from the connection without blocking on a
+// reader.
+func (wc *wsConn) deliver(data []byte) {
+	wc.mu.Lock()
+	wc.buf.Write(data)
+	wc.cond.Signal()
+	wc.mu.Unlock()
+}
+
+func (wc *wsConn) closeWithError(err error) {
+	wc.mu.Lock()
+	if !wc.closed {
+		wc.closed = true
+		wc.err = err
+		wc.cond.Broadcast()
+	}
+	wc.mu.Unlock()
+}
+
+func (wc *wsConn) Read(p []byte) (int, error) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	for wc.buf.Len() == 0 && !wc.closed {
+		wc.cond.Wait()
+	}
+
+	if wc.buf.Len() == 0 {
+		if wc.err != nil {
+			return 0, wc.err
+		}
+		return 0, io.EOF
+	}
+
+	return wc.buf.Read(p)
+}
+
+func (wc *wsConn) Write(p []byte) (int, error) {
+	if _, err := wc.c.WriteBinary(p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (wc *wsConn) Close() error {
+	wc.closeWithError(io.ErrClosedPipe)
+
+	// Write the close frame directly rather than calling wc.c.Close():
+	// that also does its own ReadFrame to wait for the peer's close ack,
+	// racing with readLoop's ReadFrame call on the same *Client. readLoop
+	// already watches for the peer's close frame (and any read error) and
+	// exits on its own, so Close only needs to send ours and tear down
+	// the underlying conn.
+	fr := AcquireFrame()
+	fr.SetClose()
+	fr.SetFin()
+	fr.SetStatus(StatusNone)
+
+	_, err := wc.c.WriteFrame(fr)
+	ReleaseFrame(fr)
+
+	if cerr := wc.c.c.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func (wc *wsConn) LocalAddr() net.Addr  { return wc.c.c.LocalAddr() }
+func (wc *wsConn) RemoteAddr() net.Addr { return wc.c.c.RemoteAddr() }
+
+func (wc *wsConn) SetDeadline(t time.Time) error      { return wc.c.c.SetDeadline(t) }
+func (wc *wsConn) SetReadDeadline(t time.Time) error  { return wc.c.c.SetReadDeadline(t) }
+func (wc *wsConn) SetWriteDeadline(t time.Time) error { return wc.c.c.SetWriteDeadline(t) }
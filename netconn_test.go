@@ -0,0 +1,184 @@
+package websocket
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func newNetConnPair(t *testing.T) (sender, receiver *Client) {
+	t.Helper()
+
+	cConn, sConn := net.Pipe()
+	t.Cleanup(func() { cConn.Close(); sConn.Close() })
+
+	sender = &Client{c: cConn, brw: bufio.NewReadWriter(bufio.NewReader(cConn), bufio.NewWriter(cConn))}
+	receiver = &Client{c: sConn, brw: bufio.NewReadWriter(bufio.NewReader(sConn), bufio.NewWriter(sConn))}
+
+	return sender, receiver
+}
+
+// TestNetConnReadWrite proves the basic round trip: a binary frame
+// written by one side of the Client pair shows up on the NetConn backed
+// by the other, and writes through NetConn go out as binary frames.
+func TestNetConnReadWrite(t *testing.T) {
+	sender, receiver := newNetConnPair(t)
+
+	nc := receiver.NetConn()
+	defer nc.Close()
+
+	// nc.Close() writes a close frame back to sender; drain it (and
+	// anything else arriving on sender) in the background so that write
+	// doesn't block forever on the net.Pipe once the test returns.
+	go func() {
+		fr := AcquireFrame()
+		defer ReleaseFrame(fr)
+		for {
+			if _, err := sender.ReadFrame(fr); err != nil {
+				return
+			}
+		}
+	}()
+
+	go sender.WriteBinary([]byte("hello over net.Conn"))
+
+	buf := make([]byte, 64)
+	n, err := nc.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got := string(buf[:n]); got != "hello over net.Conn" {
+		t.Fatalf("Read = %q, want %q", got, "hello over net.Conn")
+	}
+}
+
+// TestNetConnSlowReaderDoesNotStallPingHandling covers the hazard fixed
+// alongside channel.go's demux loop (c3275c7): delivering an incoming
+// payload through an unbuffered io.Pipe blocks readLoop until NetConn's
+// Read is called, so a consumer that never reads stalls the single
+// goroutine driving ReadFrame — and with it, ping/pong keepalive
+// handling on the underlying Client. Buffered delivery must let readLoop
+// keep servicing the connection regardless of whether anyone is reading.
+func TestNetConnSlowReaderDoesNotStallPingHandling(t *testing.T) {
+	sender, receiver := newNetConnPair(t)
+
+	nc := receiver.NetConn()
+	_ = nc // readLoop, started by NetConn(), is what services the ping below
+	// Close the raw conn directly rather than nc.Close(): that writes a
+	// close frame back to sender — but sender's brw is already being read/written manually
+	// below, so a close-frame write here would either race with that or,
+	// once the test's manual exchange is done, block forever with nothing
+	// left to drain it.
+	defer receiver.c.Close()
+
+	// Fill the buffer with messages nobody reads yet.
+	for i := 0; i < 5; i++ {
+		if _, err := sender.WriteBinary([]byte("queued")); err != nil {
+			t.Fatalf("WriteBinary: %v", err)
+		}
+	}
+
+	// A ping sent after those unread messages must still be observed and
+	// answered by receiver's ReadFrame, proving readLoop never blocked
+	// delivering the backlog to the idle consumer.
+	ping := AcquireFrame()
+	ping.SetFin()
+	ping.SetPing()
+	ping.Mask()
+	_, err := ping.WriteTo(sender.brw)
+	if err == nil {
+		err = sender.brw.Flush()
+	}
+	ReleaseFrame(ping)
+	if err != nil {
+		t.Fatalf("write ping: %v", err)
+	}
+
+	sender.c.SetReadDeadline(time.Now().Add(time.Second))
+
+	pong := AcquireFrame()
+	defer ReleaseFrame(pong)
+	if _, err := pong.ReadFrom(sender.brw); err != nil {
+		t.Fatalf("ReadFrom(pong): %v", err)
+	}
+	if !pong.IsPong() {
+		t.Fatalf("expected a pong frame in reply to the ping")
+	}
+}
+
+// TestNetConnConcurrentReadWrite runs NetConn.Read and NetConn.Write
+// concurrently with the peer doing the same on the underlying Client,
+// the usage pattern net.Conn-oriented code (net/rpc, *tls.Conn) relies
+// on. Run with -race to catch a regression in wsConn's buffer locking.
+func TestNetConnConcurrentReadWrite(t *testing.T) {
+	sender, receiver := newNetConnPair(t)
+
+	nc := receiver.NetConn()
+	defer nc.Close()
+
+	const messages = 20
+
+	drainDone := make(chan struct{})
+	go func() {
+		fr := AcquireFrame()
+		defer ReleaseFrame(fr)
+		for i := 0; i < messages; i++ {
+			if _, err := sender.ReadFrame(fr); err != nil {
+				close(drainDone)
+				return
+			}
+		}
+		close(drainDone)
+
+		// Keep draining after the expected messages: nc.Close()'s deferred
+		// close-frame write still needs a reader on sender's side, or it
+		// blocks forever on the net.Pipe once the test returns.
+		for {
+			if _, err := sender.ReadFrame(fr); err != nil {
+				return
+			}
+		}
+	}()
+
+	writeDone := make(chan error, 1)
+	go func() {
+		for i := 0; i < messages; i++ {
+			if _, err := nc.Write([]byte("pong!")); err != nil {
+				writeDone <- err
+				return
+			}
+		}
+		writeDone <- nil
+	}()
+
+	readDone := make(chan error, 1)
+	go func() {
+		for i := 0; i < messages; i++ {
+			if _, err := sender.WriteBinary([]byte("ping!")); err != nil {
+				readDone <- err
+				return
+			}
+		}
+		readDone <- nil
+	}()
+
+	buf := make([]byte, 5)
+	for i := 0; i < messages; i++ {
+		if _, err := io.ReadFull(nc, buf); err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if string(buf) != "ping!" {
+			t.Fatalf("Read = %q, want %q", buf, "ping!")
+		}
+	}
+
+	if err := <-writeDone; err != nil {
+		t.Fatalf("nc.Write: %v", err)
+	}
+	if err := <-readDone; err != nil {
+		t.Fatalf("sender.WriteBinary: %v", err)
+	}
+	<-drainDone
+}
@@ -0,0 +1,142 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	b64 "encoding/base64"
+	"errors"
+	"net"
+
+	"github.com/valyala/fasthttp"
+)
+
+// websocketGUID is appended to the client's Sec-WebSocket-Key before
+// hashing to produce Sec-WebSocket-Accept, per RFC 6455 §1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrNotWebsocketUpgrade is returned when the request does not carry the
+// headers required to upgrade to a WebSocket connection.
+var ErrNotWebsocketUpgrade = errors.New("websocket: not an upgrade request")
+
+// Upgrader upgrades incoming fasthttp requests to WebSocket connections.
+//
+// It mirrors ClientConfig's subprotocol negotiation so that both sides of
+// a connection can agree on things like "chat", "graphql-ws" or
+// "channel.k8s.io" without callers hand-editing headers.
+type Upgrader struct {
+	// Subprotocols lists, in preference order, the application
+	// subprotocols this server supports.
+	Subprotocols []string
+
+	// EnableCompression accepts a client's permessage-deflate offer (RFC
+	// 7692) when present, and configures the resulting Client to
+	// compress outgoing data frames and decompress incoming ones,
+	// mirroring ClientConfig.EnableCompression.
+	EnableCompression bool
+
+	// CompressionLevel is the flate compression level to use for
+	// outgoing frames. Defaults to flate.DefaultCompression when zero.
+	CompressionLevel int
+
+	// CompressionThreshold is the minimum payload size, in bytes, a
+	// message must reach before it is compressed. Messages smaller than
+	// this are sent uncompressed regardless of EnableCompression.
+	CompressionThreshold int
+}
+
+// Upgrade hijacks ctx's connection, completes the WebSocket handshake
+// (negotiating a subprotocol from u.Subprotocols when requested) and
+// invokes handler with the resulting *Client.
+func (u *Upgrader) Upgrade(ctx *fasthttp.RequestCtx, handler func(*Client)) error {
+	if !equalsFold(ctx.Request.Header.PeekBytes(upgradeString), websocketString) {
+		return ErrNotWebsocketUpgrade
+	}
+
+	key := ctx.Request.Header.PeekBytes(wsHeaderKey)
+	if len(key) == 0 {
+		return ErrNotWebsocketUpgrade
+	}
+
+	accept := acceptKey(key)
+
+	var subprotocol string
+	if len(u.Subprotocols) > 0 {
+		subprotocol = selectSubprotocol(u.Subprotocols, ctx.Request.Header.PeekBytes(wsHeaderProtocol))
+	}
+
+	var compression *compressionParams
+	if u.EnableCompression {
+		compression = parseCompressionOffer(ctx.Request.Header.PeekBytes(wsHeaderExtensions))
+	}
+
+	ctx.Response.SetStatusCode(101)
+	ctx.Response.Header.AddBytesKV(connectionString, upgradeString)
+	ctx.Response.Header.AddBytesKV(upgradeString, websocketString)
+	ctx.Response.Header.Set("Sec-WebSocket-Accept", accept)
+
+	if subprotocol != "" {
+		ctx.Response.Header.Set(string(wsHeaderProtocol), subprotocol)
+	}
+
+	if compression != nil {
+		ctx.Response.Header.AddBytesKV(wsHeaderExtensions, compressionAccept(compression))
+	}
+
+	ctx.Hijack(func(c net.Conn) {
+		cl := &Client{
+			c: c,
+			brw: bufio.NewReadWriter(
+				bufio.NewReader(c), bufio.NewWriter(c)),
+			isServer:             true,
+			subprotocol:          subprotocol,
+			compressionThreshold: u.CompressionThreshold,
+		}
+
+		if compression != nil {
+			// Mirrors client(): the server's own writes honor
+			// server_no_context_takeover, and it inflates the client's
+			// frames honoring whatever the client negotiated for itself.
+			cl.deflater = newMessageDeflater(u.CompressionLevel, compression.serverNoContextTakeover)
+			cl.inflater = newMessageInflater(compression.clientNoContextTakeover)
+		}
+
+		handler(cl)
+	})
+
+	return nil
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for a client's
+// Sec-WebSocket-Key, per RFC 6455 §1.3.
+func acceptKey(key []byte) string {
+	h := sha1.New()
+	h.Write(key)
+	h.Write([]byte(websocketGUID))
+	return b64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// selectSubprotocol returns the first of supported, in order, that also
+// appears in the client's comma-separated Sec-WebSocket-Protocol header,
+// so the server's preference order (not the client's) decides ties, or
+// "" if none match.
+func selectSubprotocol(supported []string, requested []byte) string {
+	if len(requested) == 0 {
+		return ""
+	}
+
+	var wanted []string
+	for _, field := range bytes.Split(requested, []byte(",")) {
+		wanted = append(wanted, string(bytes.TrimSpace(field)))
+	}
+
+	for _, have := range supported {
+		for _, want := range wanted {
+			if have == want {
+				return have
+			}
+		}
+	}
+
+	return ""
+}
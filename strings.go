@@ -0,0 +1,19 @@
+package websocket
+
+// Wire constants shared by the client and server handshake paths.
+var (
+	wsString         = []byte("ws")
+	originString     = []byte("Origin")
+	connectionString = []byte("Connection")
+	upgradeString    = []byte("Upgrade")
+	websocketString  = []byte("WebSocket")
+	wsHeaderVersion  = []byte("Sec-WebSocket-Version")
+	wsHeaderKey      = []byte("Sec-WebSocket-Key")
+
+	// supportedVersions is a slice, rather than a single value, so a
+	// future RFC revision can be offered alongside "13" without an API
+	// change.
+	supportedVersions = [][]byte{
+		[]byte("13"),
+	}
+)
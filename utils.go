@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	b64 "encoding/base64"
+	"net/url"
+	"unsafe"
+
+	"github.com/valyala/fasthttp"
+)
+
+// base64 is the encoding makeRandKey uses for Sec-WebSocket-Key, kept as
+// a package-level value (rather than calling b64.StdEncoding inline) to
+// match appendEncode's signature.
+var base64 = b64.StdEncoding
+
+// appendEncode base64-encodes src using enc and appends the result to
+// dst.
+func appendEncode(enc *b64.Encoding, dst, src []byte) []byte {
+	n := len(dst)
+	dst = extendByteSlice(dst, n+enc.EncodedLen(len(src)))
+	enc.Encode(dst[n:], src)
+	return dst
+}
+
+// b2s reinterprets b as a string without copying. The caller must not
+// mutate b afterwards.
+func b2s(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+// equalsFold reports whether b and s are equal, ignoring ASCII case, as
+// used to compare header values like "Upgrade: websocket".
+func equalsFold(b, s []byte) bool {
+	if len(b) != len(s) {
+		return false
+	}
+	for i := range b {
+		if b[i]|0x20 != s[i]|0x20 {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareOrigin derives the Origin header value from the target URI,
+// e.g. "http://example.com" for a request to "http://example.com/path".
+// The scheme is copied as is, so a ws:// URI yields a ws:// origin.
+func prepareOrigin(b []byte, uri *fasthttp.URI) []byte {
+	b = append(b[:0], uri.Scheme()...)
+	b = append(b, "://"...)
+	return append(b, uri.Host()...)
+}
+
+// prepareCustomOrigin validates and copies a caller-supplied
+// ClientConfig.Origin, used instead of prepareOrigin when cconfig.using
+// is set.
+func prepareCustomOrigin(b []byte, origin string) ([]byte, error) {
+	if _, err := url.Parse(origin); err != nil {
+		return nil, err
+	}
+	return append(b[:0], origin...), nil
+}